@@ -0,0 +1,173 @@
+// Package cluster adds Raft-based replication on top of store.KeyValueDB,
+// so a redisstore deployment can run as a fault-tolerant group of nodes
+// instead of a single process.
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/raft"
+	boltdb "github.com/hashicorp/raft-boltdb/v2"
+	"github.com/tiwari91/redisstore/internal/store"
+)
+
+// ErrNotLeader is returned by write-path helpers when this node is a
+// follower; callers should translate it into a RESP "-MOVED" reply that
+// points the client at the current leader.
+var ErrNotLeader = fmt.Errorf("ERR not leader")
+
+// Config holds the settings needed to stand up a Raft-backed node.
+type Config struct {
+	NodeID   string
+	RaftAddr string
+	RaftDir  string
+	// Bootstrap is true only for the node that forms a brand-new cluster.
+	Bootstrap bool
+}
+
+// Cluster wraps a raft.Raft instance and the FSM it drives.
+type Cluster struct {
+	raft *raft.Raft
+	fsm  *FSM
+}
+
+// New starts Raft for db using cfg, opening (or creating) its BoltDB log
+// store and stable store under cfg.RaftDir.
+func New(cfg Config, db *store.KeyValueDB) (*Cluster, error) {
+	if err := os.MkdirAll(cfg.RaftDir, 0o755); err != nil {
+		return nil, fmt.Errorf("cluster: create raft dir: %w", err)
+	}
+
+	raftConfig := raft.DefaultConfig()
+	raftConfig.LocalID = raft.ServerID(cfg.NodeID)
+
+	addr, err := net.ResolveTCPAddr("tcp", cfg.RaftAddr)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: resolve raft addr: %w", err)
+	}
+	transport, err := raft.NewTCPTransport(cfg.RaftAddr, addr, 3, 10*time.Second, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: create transport: %w", err)
+	}
+
+	snapshots, err := raft.NewFileSnapshotStore(cfg.RaftDir, 2, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: create snapshot store: %w", err)
+	}
+
+	logStore, err := boltdb.NewBoltStore(filepath.Join(cfg.RaftDir, "raft-log.bolt"))
+	if err != nil {
+		return nil, fmt.Errorf("cluster: create log store: %w", err)
+	}
+	stableStore, err := boltdb.NewBoltStore(filepath.Join(cfg.RaftDir, "raft-stable.bolt"))
+	if err != nil {
+		return nil, fmt.Errorf("cluster: create stable store: %w", err)
+	}
+
+	fsm := newFSM(db)
+	r, err := raft.NewRaft(raftConfig, fsm, logStore, stableStore, snapshots, transport)
+	if err != nil {
+		return nil, fmt.Errorf("cluster: create raft: %w", err)
+	}
+
+	if cfg.Bootstrap {
+		configuration := raft.Configuration{
+			Servers: []raft.Server{
+				{ID: raftConfig.LocalID, Address: transport.LocalAddr()},
+			},
+		}
+		r.BootstrapCluster(configuration)
+	}
+
+	return &Cluster{raft: r, fsm: fsm}, nil
+}
+
+// IsLeader reports whether this node currently holds Raft leadership.
+func (c *Cluster) IsLeader() bool {
+	return c.raft.State() == raft.Leader
+}
+
+// LeaderAddr returns the Raft address of the current leader, if known,
+// for use in a "-MOVED <addr>" redirect.
+func (c *Cluster) LeaderAddr() string {
+	addr, _ := c.raft.LeaderWithID()
+	return string(addr)
+}
+
+// applyOp replicates o across the cluster and returns the FSM's result,
+// distinguishing a Raft-level failure (err) from a business-logic one
+// recorded on OpResult.Err (e.g. WRONGTYPE) — both surface as an error so
+// callers never mistake a failed write for a successful one.
+func (c *Cluster) applyOp(o op, timeout time.Duration) (string, error) {
+	if !c.IsLeader() {
+		return "", ErrNotLeader
+	}
+	future := c.raft.Apply(encodeOp(o), timeout)
+	if err := future.Error(); err != nil {
+		return "", err
+	}
+	result, _ := future.Response().(OpResult)
+	if result.Err != nil {
+		return "", result.Err
+	}
+	return result.Value, nil
+}
+
+// Set replicates a SET across the cluster.
+func (c *Cluster) Set(key, value string) (string, error) {
+	return c.applyOp(op{code: opSet, key: key, value: value}, 5*time.Second)
+}
+
+// Delete replicates a DELETE across the cluster.
+func (c *Cluster) Delete(key string) (string, error) {
+	return c.applyOp(op{code: opDelete, key: key}, 5*time.Second)
+}
+
+// Incr replicates an INCR/INCRBY across the cluster.
+func (c *Cluster) Incr(key string, by int64) (string, error) {
+	return c.applyOp(op{code: opIncrBy, key: key, delta: by}, 5*time.Second)
+}
+
+// Exec replicates a MULTI/EXEC transaction (watched key versions plus
+// queued commands) as a single atomic Raft log entry.
+func (c *Cluster) Exec(watch map[string]int64, cmds []store.TxCommand) (TxResult, error) {
+	if !c.IsLeader() {
+		return TxResult{}, ErrNotLeader
+	}
+	future := c.raft.Apply(encodeTx(watch, cmds), 5*time.Second)
+	if err := future.Error(); err != nil {
+		return TxResult{}, err
+	}
+	result, _ := future.Response().(TxResult)
+	return result, nil
+}
+
+// Join adds nodeID, reachable at addr, as a voter in the cluster. Must be
+// called against the current leader.
+func (c *Cluster) Join(nodeID, addr string) error {
+	if !c.IsLeader() {
+		return ErrNotLeader
+	}
+	future := c.raft.AddVoter(raft.ServerID(nodeID), raft.ServerAddress(addr), 0, 10*time.Second)
+	return future.Error()
+}
+
+// Leave removes nodeID from the cluster. Must be called against the
+// current leader.
+func (c *Cluster) Leave(nodeID string) error {
+	if !c.IsLeader() {
+		return ErrNotLeader
+	}
+	future := c.raft.RemoveServer(raft.ServerID(nodeID), 0, 10*time.Second)
+	return future.Error()
+}
+
+// Stats exposes raft.Raft's own diagnostic counters (state, term, last
+// log index, peer count, ...) for the RAFT.STATS admin command.
+func (c *Cluster) Stats() map[string]string {
+	return c.raft.Stats()
+}
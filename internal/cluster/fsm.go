@@ -0,0 +1,111 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/raft"
+	"github.com/tiwari91/redisstore/internal/store"
+)
+
+// FSM adapts store.KeyValueDB to raft.FSM so every SET/DELETE/INCR/INCRBY/EXEC
+// committed by the Raft leader is replayed identically on every follower.
+type FSM struct {
+	db *store.KeyValueDB
+}
+
+// TxResult is the value an EXEC's raft.Apply() future resolves to.
+type TxResult struct {
+	Replies   []store.Reply
+	Committed bool
+}
+
+// OpResult is the value a SET/DELETE/INCR/INCRBY raft.Apply() future
+// resolves to. Err is set when the op failed for a business-logic reason
+// (e.g. WRONGTYPE) rather than a Raft-level failure, so callers can tell
+// that apart from a successful Value like "OK" or "1" instead of writing
+// both back to the client as if they'd succeeded.
+type OpResult struct {
+	Err   error
+	Value string
+}
+
+// newFSM wraps db for use as a Raft finite state machine.
+func newFSM(db *store.KeyValueDB) *FSM {
+	return &FSM{db: db}
+}
+
+// Apply decodes a single raft.Log entry and replays it against the
+// underlying KeyValueDB. The return value becomes the result handed back
+// to whichever caller's raft.Apply() future is waiting on this index.
+func (f *FSM) Apply(l *raft.Log) interface{} {
+	if len(l.Data) == 0 {
+		return nil
+	}
+
+	if opCode(l.Data[0]) == opExec {
+		watch, cmds, err := decodeTx(l.Data)
+		if err != nil {
+			return err
+		}
+		replies, committed := f.db.ExecTx(watch, cmds)
+		return TxResult{Replies: replies, Committed: committed}
+	}
+
+	decoded, err := decodeOp(l.Data)
+	if err != nil {
+		return OpResult{Err: err}
+	}
+	return f.applyOne(decoded)
+}
+
+// applyOne performs a single decoded op against the store and returns a
+// tagged OpResult, mirroring how applyTxCommandLocked already tags each
+// queued command's store.Reply with its kind instead of collapsing
+// success and failure into the same string.
+func (f *FSM) applyOne(o op) OpResult {
+	switch o.code {
+	case opSet:
+		if err := f.db.Set(o.key, o.value); err != nil {
+			return OpResult{Err: err}
+		}
+		return OpResult{Value: "OK"}
+	case opDelete:
+		if f.db.Delete(o.key) {
+			return OpResult{Value: "1"}
+		}
+		return OpResult{Value: "0"}
+	case opIncr:
+		val, err := f.db.Incr(o.key, 1)
+		if err != nil {
+			return OpResult{Err: err}
+		}
+		return OpResult{Value: fmt.Sprintf("%d", val)}
+	case opIncrBy:
+		val, err := f.db.Incr(o.key, o.delta)
+		if err != nil {
+			return OpResult{Err: err}
+		}
+		return OpResult{Value: fmt.Sprintf("%d", val)}
+	default:
+		return OpResult{Err: fmt.Errorf("ERR unknown raft op %d", o.code)}
+	}
+}
+
+// Snapshot captures the current data set, lists, and TTLs so Raft can
+// truncate its log.
+func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	return &fsmSnapshot{snap: f.db.Snapshot()}, nil
+}
+
+// Restore replaces the FSM's state with a previously captured snapshot,
+// used when a follower is too far behind and must be fast-forwarded.
+func (f *FSM) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+	snap, err := readSnapshot(rc)
+	if err != nil {
+		return err
+	}
+	f.db.Restore(snap)
+	return nil
+}
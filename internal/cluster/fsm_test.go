@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	"github.com/tiwari91/redisstore/internal/store"
+)
+
+func TestFSMApplySetDeleteIncr(t *testing.T) {
+	db := store.NewKeyValueDB()
+	fsm := newFSM(db)
+
+	if result := fsm.Apply(&raft.Log{Data: encodeOp(op{code: opSet, key: "foo", value: "bar"})}); result != (OpResult{Value: "OK"}) {
+		t.Fatalf("Apply(SET) = %v, want {Value: OK}", result)
+	}
+	if val, ok, _ := db.Get("foo"); !ok || val != "bar" {
+		t.Fatalf("Get(foo) = %q, %v, want \"bar\", true", val, ok)
+	}
+
+	if result := fsm.Apply(&raft.Log{Data: encodeOp(op{code: opIncr, key: "n"})}); result != (OpResult{Value: "1"}) {
+		t.Fatalf("Apply(INCR) = %v, want {Value: 1}", result)
+	}
+
+	if result := fsm.Apply(&raft.Log{Data: encodeOp(op{code: opDelete, key: "foo"})}); result != (OpResult{Value: "1"}) {
+		t.Fatalf("Apply(DELETE) = %v, want {Value: 1}", result)
+	}
+	if _, ok, _ := db.Get("foo"); ok {
+		t.Fatal("Get(foo) ok = true after Apply(DELETE), want false")
+	}
+}
+
+func TestFSMApplySetWrongTypeIsTaggedAsError(t *testing.T) {
+	db := store.NewKeyValueDB()
+	db.RPush("foo", "a")
+	fsm := newFSM(db)
+
+	result := fsm.Apply(&raft.Log{Data: encodeOp(op{code: opSet, key: "foo", value: "bar"})})
+	opResult, ok := result.(OpResult)
+	if !ok {
+		t.Fatalf("Apply(SET) result type = %T, want OpResult", result)
+	}
+	if opResult.Err != store.ErrWrongType {
+		t.Fatalf("Apply(SET).Err = %v, want %v", opResult.Err, store.ErrWrongType)
+	}
+	if opResult.Value != "" {
+		t.Fatalf("Apply(SET).Value = %q on failure, want empty", opResult.Value)
+	}
+}
+
+// fakeSink is a minimal raft.SnapshotSink backed by an in-memory buffer,
+// just enough for fsmSnapshot.Persist to write to in tests.
+type fakeSink struct {
+	bytes.Buffer
+}
+
+func (s *fakeSink) ID() string    { return "test" }
+func (s *fakeSink) Cancel() error { return nil }
+func (s *fakeSink) Close() error  { return nil }
+
+func TestFSMSnapshotRestoreRoundTrip(t *testing.T) {
+	db := store.NewKeyValueDB()
+	db.Set("foo", "bar")
+	db.RPush("mylist", "a", "b")
+
+	fsm := newFSM(db)
+	snap, err := fsm.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	sink := &fakeSink{}
+	if err := snap.Persist(sink); err != nil {
+		t.Fatalf("Persist: %v", err)
+	}
+
+	restored := store.NewKeyValueDB()
+	restoredFSM := newFSM(restored)
+	if err := restoredFSM.Restore(io.NopCloser(bytes.NewReader(sink.Bytes()))); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if val, ok, _ := restored.Get("foo"); !ok || val != "bar" {
+		t.Errorf("Get(foo) = %q, %v, want \"bar\", true", val, ok)
+	}
+	values, err := restored.LRange("mylist", 0, -1)
+	if err != nil || len(values) != 2 {
+		t.Errorf("LRange(mylist) = %v, %v, want 2 elements", values, err)
+	}
+}
@@ -0,0 +1,156 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/tiwari91/redisstore/internal/store"
+)
+
+// opCode identifies the mutation carried by a raft.Log entry. Keeping the
+// wire format to a single byte opcode plus length-prefixed fields keeps the
+// Raft log compact compared to round-tripping RESP or JSON through it.
+type opCode byte
+
+const (
+	opSet opCode = iota + 1
+	opDelete
+	opIncr
+	opIncrBy
+	opExec
+)
+
+// op is the decoded form of a single non-transactional log entry.
+type op struct {
+	code  opCode
+	key   string
+	value string
+	delta int64
+}
+
+// encodeOp serializes op into the compact binary form stored in
+// raft.Log.Data: [code byte][len(key) uint32][key][len(value) uint32][value][delta int64].
+func encodeOp(o op) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(o.code))
+	writeLenPrefixed(&buf, []byte(o.key))
+	writeLenPrefixed(&buf, []byte(o.value))
+	binary.Write(&buf, binary.BigEndian, o.delta)
+	return buf.Bytes()
+}
+
+func decodeOp(data []byte) (op, error) {
+	if len(data) < 1 {
+		return op{}, fmt.Errorf("cluster: empty log entry")
+	}
+	r := bytes.NewReader(data)
+	codeByte, _ := r.ReadByte()
+	code := opCode(codeByte)
+
+	key, err := readLenPrefixed(r)
+	if err != nil {
+		return op{}, err
+	}
+	value, err := readLenPrefixed(r)
+	if err != nil {
+		return op{}, err
+	}
+	var delta int64
+	if err := binary.Read(r, binary.BigEndian, &delta); err != nil {
+		return op{}, err
+	}
+	return op{code: code, key: string(key), value: string(value), delta: delta}, nil
+}
+
+// encodeTx serializes a MULTI/EXEC transaction (the watched key versions
+// plus the queued commands) into a single opExec log entry, so the whole
+// transaction commits or aborts as one atomic Raft FSM.Apply call.
+func encodeTx(watch map[string]int64, cmds []store.TxCommand) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(opExec))
+
+	binary.Write(&buf, binary.BigEndian, uint32(len(watch)))
+	for key, version := range watch {
+		writeLenPrefixed(&buf, []byte(key))
+		binary.Write(&buf, binary.BigEndian, version)
+	}
+
+	binary.Write(&buf, binary.BigEndian, uint32(len(cmds)))
+	for _, c := range cmds {
+		writeLenPrefixed(&buf, []byte(c.Name))
+		writeLenPrefixed(&buf, []byte(c.Key))
+		writeLenPrefixed(&buf, []byte(c.Value))
+		binary.Write(&buf, binary.BigEndian, c.Delta)
+	}
+	return buf.Bytes()
+}
+
+func decodeTx(data []byte) (map[string]int64, []store.TxCommand, error) {
+	if len(data) < 1 || opCode(data[0]) != opExec {
+		return nil, nil, fmt.Errorf("cluster: not an opExec entry")
+	}
+	r := bytes.NewReader(data[1:])
+
+	var watchLen uint32
+	if err := binary.Read(r, binary.BigEndian, &watchLen); err != nil {
+		return nil, nil, err
+	}
+	watch := make(map[string]int64, watchLen)
+	for i := uint32(0); i < watchLen; i++ {
+		key, err := readLenPrefixed(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		var version int64
+		if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+			return nil, nil, err
+		}
+		watch[string(key)] = version
+	}
+
+	var cmdLen uint32
+	if err := binary.Read(r, binary.BigEndian, &cmdLen); err != nil {
+		return nil, nil, err
+	}
+	cmds := make([]store.TxCommand, 0, cmdLen)
+	for i := uint32(0); i < cmdLen; i++ {
+		name, err := readLenPrefixed(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := readLenPrefixed(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, err := readLenPrefixed(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		var delta int64
+		if err := binary.Read(r, binary.BigEndian, &delta); err != nil {
+			return nil, nil, err
+		}
+		cmds = append(cmds, store.TxCommand{Name: string(name), Key: string(key), Value: string(value), Delta: delta})
+	}
+	return watch, cmds, nil
+}
+
+func writeLenPrefixed(buf *bytes.Buffer, b []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(b)))
+	buf.Write(b)
+}
+
+func readLenPrefixed(r *bytes.Reader) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if n > 0 {
+		if _, err := r.Read(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
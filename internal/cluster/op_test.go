@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/tiwari91/redisstore/internal/store"
+)
+
+func TestEncodeDecodeOpRoundTrip(t *testing.T) {
+	want := op{code: opIncrBy, key: "foo", value: "", delta: 42}
+	got, err := decodeOp(encodeOp(want))
+	if err != nil {
+		t.Fatalf("decodeOp: %v", err)
+	}
+	if got != want {
+		t.Errorf("decodeOp(encodeOp(%+v)) = %+v", want, got)
+	}
+}
+
+func TestEncodeDecodeTxRoundTrip(t *testing.T) {
+	watch := map[string]int64{"foo": 3}
+	cmds := []store.TxCommand{
+		{Name: "SET", Key: "foo", Value: "bar"},
+		{Name: "INCRBY", Key: "n", Delta: 5},
+	}
+
+	gotWatch, gotCmds, err := decodeTx(encodeTx(watch, cmds))
+	if err != nil {
+		t.Fatalf("decodeTx: %v", err)
+	}
+	if gotWatch["foo"] != 3 {
+		t.Errorf("watch[foo] = %d, want 3", gotWatch["foo"])
+	}
+	if len(gotCmds) != 2 || gotCmds[0] != cmds[0] || gotCmds[1] != cmds[1] {
+		t.Errorf("decodeTx cmds = %+v, want %+v", gotCmds, cmds)
+	}
+}
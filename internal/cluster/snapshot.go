@@ -0,0 +1,190 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/tiwari91/redisstore/internal/store"
+)
+
+// fsmSnapshot is a point-in-time copy of the store's data, lists, and
+// TTLs, streamed to Raft's SnapshotStore in a length-prefixed binary
+// format that readSnapshot knows how to read back.
+type fsmSnapshot struct {
+	snap store.DBSnapshot
+}
+
+// Persist writes the snapshot to sink as three sections, in order: data
+// (key/value pairs), lists (key + element count + elements), and expires
+// (key + absolute expiry as UnixNano).
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	err := func() error {
+		if err := writeUint32(sink, uint32(len(s.snap.Data))); err != nil {
+			return err
+		}
+		for k, v := range s.snap.Data {
+			if err := writeField(sink, k); err != nil {
+				return err
+			}
+			if err := writeField(sink, v); err != nil {
+				return err
+			}
+		}
+
+		if err := writeUint32(sink, uint32(len(s.snap.Lists))); err != nil {
+			return err
+		}
+		for k, values := range s.snap.Lists {
+			if err := writeField(sink, k); err != nil {
+				return err
+			}
+			if err := writeUint32(sink, uint32(len(values))); err != nil {
+				return err
+			}
+			for _, v := range values {
+				if err := writeField(sink, v); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := writeUint32(sink, uint32(len(s.snap.Expires))); err != nil {
+			return err
+		}
+		for k, t := range s.snap.Expires {
+			if err := writeField(sink, k); err != nil {
+				return err
+			}
+			if err := writeInt64(sink, t.UnixNano()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+// Release is a no-op; the snapshot holds no external resources.
+func (s *fsmSnapshot) Release() {}
+
+func writeField(w io.Writer, s string) error {
+	if err := writeUint32(w, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte(s))
+	return err
+}
+
+func writeUint32(w io.Writer, n uint32) error {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, n)
+	_, err := w.Write(buf)
+	return err
+}
+
+func writeInt64(w io.Writer, n int64) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(n))
+	_, err := w.Write(buf)
+	return err
+}
+
+// readSnapshot reads back the format written by Persist.
+func readSnapshot(r io.Reader) (store.DBSnapshot, error) {
+	dataCount, err := readUint32(r)
+	if err != nil {
+		return store.DBSnapshot{}, err
+	}
+	data := make(map[string]string, dataCount)
+	for i := uint32(0); i < dataCount; i++ {
+		key, err := readField(r)
+		if err != nil {
+			return store.DBSnapshot{}, err
+		}
+		value, err := readField(r)
+		if err != nil {
+			return store.DBSnapshot{}, err
+		}
+		data[key] = value
+	}
+
+	listCount, err := readUint32(r)
+	if err != nil {
+		return store.DBSnapshot{}, err
+	}
+	lists := make(map[string][]string, listCount)
+	for i := uint32(0); i < listCount; i++ {
+		key, err := readField(r)
+		if err != nil {
+			return store.DBSnapshot{}, err
+		}
+		elemCount, err := readUint32(r)
+		if err != nil {
+			return store.DBSnapshot{}, err
+		}
+		values := make([]string, 0, elemCount)
+		for j := uint32(0); j < elemCount; j++ {
+			v, err := readField(r)
+			if err != nil {
+				return store.DBSnapshot{}, err
+			}
+			values = append(values, v)
+		}
+		lists[key] = values
+	}
+
+	expireCount, err := readUint32(r)
+	if err != nil {
+		return store.DBSnapshot{}, err
+	}
+	expires := make(map[string]time.Time, expireCount)
+	for i := uint32(0); i < expireCount; i++ {
+		key, err := readField(r)
+		if err != nil {
+			return store.DBSnapshot{}, err
+		}
+		nanos, err := readInt64(r)
+		if err != nil {
+			return store.DBSnapshot{}, err
+		}
+		expires[key] = time.Unix(0, nanos)
+	}
+
+	return store.DBSnapshot{Data: data, Lists: lists, Expires: expires}, nil
+}
+
+func readField(r io.Reader) (string, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if n > 0 {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+	}
+	return string(buf), nil
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf), nil
+}
+
+func readInt64(r io.Reader) (int64, error) {
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(buf)), nil
+}
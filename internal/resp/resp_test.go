@@ -0,0 +1,77 @@
+package resp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadCommandInline(t *testing.T) {
+	r := NewReader(strings.NewReader("SET foo bar\r\n"))
+	got, err := r.ReadCommand()
+	if err != nil {
+		t.Fatalf("ReadCommand: %v", err)
+	}
+	want := []string{"SET", "foo", "bar"}
+	if !equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadCommandArrayBulkString(t *testing.T) {
+	raw := "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$11\r\nhello world\r\n"
+	r := NewReader(strings.NewReader(raw))
+	got, err := r.ReadCommand()
+	if err != nil {
+		t.Fatalf("ReadCommand: %v", err)
+	}
+	want := []string{"SET", "foo", "hello world"}
+	if !equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadCommandEmptyLine(t *testing.T) {
+	r := NewReader(strings.NewReader("\r\n"))
+	got, err := r.ReadCommand()
+	if err != nil {
+		t.Fatalf("ReadCommand: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %q, want empty", got)
+	}
+}
+
+func TestWriteBulkStringRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteBulkString("hello world"); err != nil {
+		t.Fatalf("WriteBulkString: %v", err)
+	}
+	if buf.String() != "$11\r\nhello world\r\n" {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func TestWriteNullArray(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteNullArray(); err != nil {
+		t.Fatalf("WriteNullArray: %v", err)
+	}
+	if buf.String() != "*-1\r\n" {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
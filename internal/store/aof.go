@@ -0,0 +1,140 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tiwari91/redisstore/internal/resp"
+)
+
+// FsyncPolicy controls how aggressively the AOF is flushed to disk.
+type FsyncPolicy string
+
+const (
+	// FsyncAlways fsyncs after every appended command; safest, slowest.
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncEverySec fsyncs once per second via a background ticker.
+	FsyncEverySec FsyncPolicy = "everysec"
+	// FsyncNo leaves fsync timing to the OS.
+	FsyncNo FsyncPolicy = "no"
+)
+
+// aof is an append-only log of every mutating command, written as a RESP
+// array of bulk strings so it can be replayed with the same resp.Reader
+// used to parse client connections.
+type aof struct {
+	mu     sync.Mutex
+	path   string
+	f      *os.File
+	policy FsyncPolicy
+	done   chan struct{}
+}
+
+// openAOF opens (creating if necessary) the AOF file at path in append
+// mode and, for FsyncEverySec, starts the background fsync ticker.
+func openAOF(path string, policy FsyncPolicy) (*aof, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("store: open aof: %w", err)
+	}
+	a := &aof{path: path, f: f, policy: policy}
+	if policy == FsyncEverySec {
+		a.done = make(chan struct{})
+		go a.fsyncLoop()
+	}
+	return a, nil
+}
+
+func (a *aof) fsyncLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.mu.Lock()
+			a.f.Sync()
+			a.mu.Unlock()
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// append writes cmd (e.g. ["SET", "foo", "bar"]) to the log and, under the
+// "always" policy, fsyncs before returning.
+func (a *aof) append(cmd []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	w := resp.NewWriter(a.f)
+	if err := w.WriteArrayHeader(len(cmd)); err != nil {
+		return err
+	}
+	for _, field := range cmd {
+		if err := w.WriteBulkString(field); err != nil {
+			return err
+		}
+	}
+	if a.policy == FsyncAlways {
+		return a.f.Sync()
+	}
+	return nil
+}
+
+// size returns the current AOF file size in bytes.
+func (a *aof) size() (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	info, err := a.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// replace atomically swaps the live AOF file for one containing data,
+// used by AOF.REWRITE to compact the log down to its minimal form.
+func (a *aof) replace(data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tmpPath := a.path + ".rewrite.tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("store: create aof rewrite temp file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("store: write aof rewrite temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, a.path); err != nil {
+		return fmt.Errorf("store: rename aof rewrite temp file: %w", err)
+	}
+
+	a.f.Close()
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("store: reopen aof after rewrite: %w", err)
+	}
+	a.f = f
+	return nil
+}
+
+func (a *aof) close() error {
+	if a.done != nil {
+		close(a.done)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.f.Close()
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"strconv"
+	"time"
+)
+
+// activeExpireInterval is how often the active expiration cycle samples
+// for expired keys, matching Redis's default 100ms cadence.
+const activeExpireInterval = 100 * time.Millisecond
+
+// activeExpireSampleSize is how many keys the active expiration cycle
+// samples per pass.
+const activeExpireSampleSize = 20
+
+// activeExpireThreshold is the fraction of a sample that must be expired
+// for the active expiration cycle to immediately resample instead of
+// waiting for the next tick.
+const activeExpireThreshold = 0.25
+
+// expireIfNeededLocked lazily deletes key if it has an expiry in the past.
+// Callers must already hold db.mu for writing.
+func (db *KeyValueDB) expireIfNeededLocked(key string) {
+	exp, ok := db.expires[key]
+	if !ok || time.Now().Before(exp) {
+		return
+	}
+	delete(db.data, key)
+	delete(db.lists, key)
+	delete(db.expires, key)
+	db.versions[key]++
+}
+
+func (db *KeyValueDB) existsLocked(key string) bool {
+	if _, ok := db.data[key]; ok {
+		return true
+	}
+	_, ok := db.lists[key]
+	return ok
+}
+
+// Expire sets key to expire after ttl, returning false if key doesn't
+// exist.
+func (db *KeyValueDB) Expire(key string, ttl time.Duration) bool {
+	return db.ExpireAt(key, time.Now().Add(ttl))
+}
+
+// ExpireAt sets key to expire at the given instant, returning false if
+// key doesn't exist. An instant in the past expires the key immediately.
+func (db *KeyValueDB) ExpireAt(key string, at time.Time) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.expireIfNeededLocked(key)
+	if !db.existsLocked(key) {
+		return false
+	}
+	db.expires[key] = at
+	db.expireIfNeededLocked(key)
+	if db.persist != nil {
+		db.persist.LogCommand("EXPIREAT", key, strconv.FormatInt(at.Unix(), 10))
+	}
+	return true
+}
+
+// Persist removes key's expiry, returning false if key doesn't exist or
+// has no expiry set.
+func (db *KeyValueDB) Persist(key string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.expireIfNeededLocked(key)
+	if _, ok := db.expires[key]; !ok {
+		return false
+	}
+	delete(db.expires, key)
+	if db.persist != nil {
+		db.persist.LogCommand("PERSIST", key)
+	}
+	return true
+}
+
+// TTL returns the remaining time to live for key in seconds: -2 if key
+// doesn't exist, -1 if it exists but has no expiry.
+func (db *KeyValueDB) TTL(key string) int64 {
+	ttl := db.PTTL(key)
+	if ttl < 0 {
+		return ttl
+	}
+	return int64(time.Duration(ttl) * time.Millisecond / time.Second)
+}
+
+// PTTL is TTL with millisecond precision.
+func (db *KeyValueDB) PTTL(key string) int64 {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.expireIfNeededLocked(key)
+	if !db.existsLocked(key) {
+		return -2
+	}
+	exp, ok := db.expires[key]
+	if !ok {
+		return -1
+	}
+	remaining := time.Until(exp)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining.Milliseconds()
+}
+
+// startActiveExpiration runs the background sampling loop described by
+// Redis's active expiration cycle: every activeExpireInterval, sample up
+// to activeExpireSampleSize keys with an expiry set and delete the ones
+// that are due; if more than activeExpireThreshold of the sample was
+// expired, resample immediately instead of waiting for the next tick.
+//
+// Keyspace notifications (e.g. "keyspace@0__:expired") are not published
+// here: redisstore has no pub/sub subsystem yet for this to publish to.
+func (db *KeyValueDB) startActiveExpiration() {
+	ticker := time.NewTicker(activeExpireInterval)
+	go func() {
+		defer ticker.Stop()
+		for range ticker.C {
+			for db.activeExpireCycle() {
+			}
+		}
+	}()
+}
+
+// activeExpireCycle runs one sampling pass and reports whether the
+// expired fraction was high enough to warrant an immediate resample.
+func (db *KeyValueDB) activeExpireCycle() bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if len(db.expires) == 0 {
+		return false
+	}
+
+	sampleSize := activeExpireSampleSize
+	if sampleSize > len(db.expires) {
+		sampleSize = len(db.expires)
+	}
+
+	// Go's map iteration order is randomized per iteration, so the first
+	// sampleSize keys seen approximate Redis's random key sampling
+	// without needing a separate RNG.
+	sampled := 0
+	expired := 0
+	now := time.Now()
+	for key, exp := range db.expires {
+		if sampled >= sampleSize {
+			break
+		}
+		sampled++
+		if !now.Before(exp) {
+			delete(db.data, key)
+			delete(db.lists, key)
+			delete(db.expires, key)
+			db.versions[key]++
+			expired++
+		}
+	}
+
+	return float64(expired) > activeExpireThreshold*float64(sampled)
+}
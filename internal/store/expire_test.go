@@ -0,0 +1,75 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpireAndTTL(t *testing.T) {
+	db := NewKeyValueDB()
+	db.Set("foo", "bar")
+
+	if ttl := db.TTL("foo"); ttl != -1 {
+		t.Errorf("TTL(foo) = %d, want -1 (no expiry)", ttl)
+	}
+
+	if !db.Expire("foo", time.Hour) {
+		t.Fatal("Expire(foo) = false, want true")
+	}
+	if ttl := db.TTL("foo"); ttl <= 0 || ttl > 3600 {
+		t.Errorf("TTL(foo) = %d, want in (0, 3600]", ttl)
+	}
+
+	if !db.Persist("foo") {
+		t.Fatal("Persist(foo) = false, want true")
+	}
+	if ttl := db.TTL("foo"); ttl != -1 {
+		t.Errorf("TTL(foo) = %d after Persist, want -1", ttl)
+	}
+}
+
+func TestExpireMissingKey(t *testing.T) {
+	db := NewKeyValueDB()
+	if db.Expire("missing", time.Hour) {
+		t.Error("Expire(missing) = true, want false")
+	}
+	if ttl := db.TTL("missing"); ttl != -2 {
+		t.Errorf("TTL(missing) = %d, want -2", ttl)
+	}
+}
+
+func TestLazyExpiryDeletesValueAndList(t *testing.T) {
+	db := NewKeyValueDB()
+	db.SetWithOptions("str", "v", SetOptions{HasTTL: true, TTL: -time.Second})
+	db.RPush("list", "a")
+	db.ExpireAt("list", time.Now().Add(-time.Second))
+
+	if _, ok, _ := db.Get("str"); ok {
+		t.Error("Get(str) ok = true after expiry, want false")
+	}
+	if n, err := db.LLen("list"); err != nil || n != 0 {
+		t.Errorf("LLen(list) = %d, %v after expiry, want 0, nil", n, err)
+	}
+}
+
+func TestListOpsSeeLazyExpiry(t *testing.T) {
+	db := NewKeyValueDB()
+	db.SetWithOptions("k", "v", SetOptions{HasTTL: true, TTL: -time.Second})
+
+	if _, err := db.LPush("k", "x"); err != nil {
+		t.Errorf("LPush(k) after expiry = %v, want nil (expired string key should not block a list op)", err)
+	}
+}
+
+func TestBLPopImmediateSeesLazyExpiry(t *testing.T) {
+	db := NewKeyValueDB()
+	db.RPush("list", "a")
+	db.ExpireAt("list", time.Now().Add(-time.Second))
+
+	if _, _, ok := db.BLPop([]string{"list"}, 10*time.Millisecond); ok {
+		t.Error("BLPop(list) found = true after expiry, want false")
+	}
+	if ttl := db.TTL("list"); ttl != -2 {
+		t.Errorf("TTL(list) = %d after BLPop on expired key, want -2 (expires entry cleaned up)", ttl)
+	}
+}
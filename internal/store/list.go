@@ -0,0 +1,285 @@
+package store
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrWrongType is returned when a list command targets a key holding a
+// string value, or vice versa.
+var ErrWrongType = fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// listFor returns key's list, creating it if necessary. Callers must
+// already hold db.mu.
+func (db *KeyValueDB) listFor(key string) *list.List {
+	l, ok := db.lists[key]
+	if !ok {
+		l = list.New()
+		db.lists[key] = l
+	}
+	return l
+}
+
+// LPush prepends values to key's list, creating it if necessary, and
+// returns the list's new length.
+func (db *KeyValueDB) LPush(key string, values ...string) (int64, error) {
+	db.mu.Lock()
+	db.expireIfNeededLocked(key)
+	if _, isString := db.data[key]; isString {
+		db.mu.Unlock()
+		return 0, ErrWrongType
+	}
+	l := db.listFor(key)
+	for _, v := range values {
+		l.PushFront(v)
+	}
+	db.versions[key]++
+	if db.persist != nil {
+		db.persist.LogCommand(append([]string{"LPUSH", key}, values...)...)
+	}
+	n := int64(l.Len())
+	db.mu.Unlock()
+
+	db.notifyWaiters(key)
+	return n, nil
+}
+
+// RPush appends values to key's list, creating it if necessary, and
+// returns the list's new length.
+func (db *KeyValueDB) RPush(key string, values ...string) (int64, error) {
+	db.mu.Lock()
+	db.expireIfNeededLocked(key)
+	if _, isString := db.data[key]; isString {
+		db.mu.Unlock()
+		return 0, ErrWrongType
+	}
+	l := db.listFor(key)
+	for _, v := range values {
+		l.PushBack(v)
+	}
+	db.versions[key]++
+	if db.persist != nil {
+		db.persist.LogCommand(append([]string{"RPUSH", key}, values...)...)
+	}
+	n := int64(l.Len())
+	db.mu.Unlock()
+
+	db.notifyWaiters(key)
+	return n, nil
+}
+
+// LPop removes and returns up to count elements from the head of key's
+// list. ok is false if the key doesn't exist or holds no list.
+func (db *KeyValueDB) LPop(key string, count int) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.popLocked(key, count, true)
+}
+
+// RPop removes and returns up to count elements from the tail of key's
+// list.
+func (db *KeyValueDB) RPop(key string, count int) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.popLocked(key, count, false)
+}
+
+func (db *KeyValueDB) popLocked(key string, count int, fromHead bool) ([]string, error) {
+	db.expireIfNeededLocked(key)
+	if _, isString := db.data[key]; isString {
+		return nil, ErrWrongType
+	}
+	l, ok := db.lists[key]
+	if !ok {
+		return nil, nil
+	}
+
+	popped := make([]string, 0, count)
+	for i := 0; i < count && l.Len() > 0; i++ {
+		var e *list.Element
+		if fromHead {
+			e = l.Front()
+		} else {
+			e = l.Back()
+		}
+		popped = append(popped, l.Remove(e).(string))
+	}
+	if l.Len() == 0 {
+		delete(db.lists, key)
+	}
+	if len(popped) > 0 {
+		db.versions[key]++
+		if db.persist != nil {
+			name := "RPOP"
+			if fromHead {
+				name = "LPOP"
+			}
+			db.persist.LogCommand(name, key, strconv.Itoa(len(popped)))
+		}
+	}
+	return popped, nil
+}
+
+// LLen returns the length of key's list (0 if it doesn't exist).
+func (db *KeyValueDB) LLen(key string) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.expireIfNeededLocked(key)
+	if _, isString := db.data[key]; isString {
+		return 0, ErrWrongType
+	}
+	l, ok := db.lists[key]
+	if !ok {
+		return 0, nil
+	}
+	return int64(l.Len()), nil
+}
+
+// LRange returns the elements of key's list between start and stop,
+// inclusive, supporting negative indices that count from the list's tail
+// the way Redis does.
+func (db *KeyValueDB) LRange(key string, start, stop int) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.expireIfNeededLocked(key)
+	if _, isString := db.data[key]; isString {
+		return nil, ErrWrongType
+	}
+	l, ok := db.lists[key]
+	if !ok {
+		return nil, nil
+	}
+
+	n := l.Len()
+	start = normalizeListIndex(start, n)
+	stop = normalizeListIndex(stop, n)
+	if start > stop || start >= n {
+		return []string{}, nil
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+
+	out := make([]string, 0, stop-start+1)
+	i := 0
+	for e := l.Front(); e != nil; e = e.Next() {
+		if i > stop {
+			break
+		}
+		if i >= start {
+			out = append(out, e.Value.(string))
+		}
+		i++
+	}
+	return out, nil
+}
+
+func normalizeListIndex(idx, n int) int {
+	if idx < 0 {
+		idx = n + idx
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	return idx
+}
+
+// BLPop pops from the head of the first of keys that has data, blocking
+// up to timeout (or forever, if timeout is 0) until one does. It never
+// holds db.mu while waiting: a waiting client parks a buffered channel
+// under waitMu and is woken by LPush/RPush after they release db.mu.
+func (db *KeyValueDB) BLPop(keys []string, timeout time.Duration) (key, value string, ok bool) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for {
+		if k, v, found := db.tryPopAny(keys); found {
+			return k, v, true
+		}
+
+		ch := make(chan struct{}, 1)
+		db.registerWaiter(keys, ch)
+
+		var timeoutCh <-chan time.Time
+		if timeout > 0 {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				db.deregisterWaiter(keys, ch)
+				return "", "", false
+			}
+			timeoutCh = time.After(remaining)
+		}
+
+		select {
+		case <-ch:
+			// A push happened; loop around and try to pop again.
+		case <-timeoutCh:
+			db.deregisterWaiter(keys, ch)
+			return "", "", false
+		}
+	}
+}
+
+func (db *KeyValueDB) tryPopAny(keys []string) (key, value string, found bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, k := range keys {
+		db.expireIfNeededLocked(k)
+		l, ok := db.lists[k]
+		if !ok || l.Len() == 0 {
+			continue
+		}
+		v := l.Remove(l.Front()).(string)
+		if l.Len() == 0 {
+			delete(db.lists, k)
+		}
+		db.versions[k]++
+		if db.persist != nil {
+			db.persist.LogCommand("LPOP", k, "1")
+		}
+		return k, v, true
+	}
+	return "", "", false
+}
+
+func (db *KeyValueDB) registerWaiter(keys []string, ch chan struct{}) {
+	db.waitMu.Lock()
+	defer db.waitMu.Unlock()
+	for _, k := range keys {
+		db.waiters[k] = append(db.waiters[k], ch)
+	}
+}
+
+func (db *KeyValueDB) deregisterWaiter(keys []string, ch chan struct{}) {
+	db.waitMu.Lock()
+	defer db.waitMu.Unlock()
+	for _, k := range keys {
+		waiters := db.waiters[k]
+		for i, w := range waiters {
+			if w == ch {
+				db.waiters[k] = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// notifyWaiters wakes every client blocked in BLPop on key. Must be
+// called after db.mu has been released.
+func (db *KeyValueDB) notifyWaiters(key string) {
+	db.waitMu.Lock()
+	waiters := db.waiters[key]
+	delete(db.waiters, key)
+	db.waitMu.Unlock()
+
+	for _, ch := range waiters {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tiwari91/redisstore/internal/resp"
+)
+
+// Persistence wires a KeyValueDB up to an append-only file (for durability
+// between writes) and point-in-time RDB-style snapshots (to bound AOF
+// replay time on restart).
+type Persistence struct {
+	dir      string
+	aof      *aof
+	mu       sync.Mutex
+	lastSave time.Time
+}
+
+// snapshotPath is the fixed name BGSAVE writes to; redisstore keeps a
+// single snapshot generation rather than numbered dumps.
+func (p *Persistence) snapshotPath() string {
+	return filepath.Join(p.dir, "dump.rdb")
+}
+
+// Open prepares dir for durability, opening (or creating) the AOF file.
+// It does not load any existing data; call LoadInto for that.
+func Open(dir string, policy FsyncPolicy) (*Persistence, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("store: create data dir: %w", err)
+	}
+	a, err := openAOF(filepath.Join(dir, "appendonly.aof"), policy)
+	if err != nil {
+		return nil, err
+	}
+	return &Persistence{dir: dir, aof: a}, nil
+}
+
+// LoadInto restores db from the latest snapshot (if any) and then replays
+// every command appended to the AOF since that snapshot was taken.
+func (p *Persistence) LoadInto(db *KeyValueDB) error {
+	if snap, err := loadSnapshot(p.snapshotPath()); err == nil {
+		db.Restore(snap)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("store: load snapshot: %w", err)
+	}
+
+	f, err := os.Open(p.aof.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("store: open aof for replay: %w", err)
+	}
+	defer f.Close()
+
+	reader := resp.NewReader(f)
+	for {
+		cmd, err := reader.ReadCommand()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("store: replay aof: %w", err)
+		}
+		if len(cmd) == 0 {
+			continue
+		}
+		replayCommand(db, cmd)
+	}
+	return nil
+}
+
+// replayCommand applies a single AOF-logged command directly to db,
+// bypassing AOF logging (it is already on disk) and OCC/version bumps
+// handled by the plain Set/Delete/Incr methods themselves.
+func replayCommand(db *KeyValueDB, cmd []string) {
+	switch cmd[0] {
+	case "SET":
+		if len(cmd) >= 3 {
+			db.data[cmd[1]] = cmd[2]
+			db.versions[cmd[1]]++
+		}
+	case "DELETE":
+		if len(cmd) >= 2 {
+			delete(db.data, cmd[1])
+			db.versions[cmd[1]]++
+		}
+	case "INCRBY":
+		if len(cmd) >= 3 {
+			var delta int64
+			fmt.Sscanf(cmd[2], "%d", &delta)
+			db.incrLocked(cmd[1], delta)
+			db.versions[cmd[1]]++
+		}
+	case "LPUSH":
+		if len(cmd) >= 3 {
+			l := db.listFor(cmd[1])
+			for _, v := range cmd[2:] {
+				l.PushFront(v)
+			}
+			db.versions[cmd[1]]++
+		}
+	case "RPUSH":
+		if len(cmd) >= 3 {
+			l := db.listFor(cmd[1])
+			for _, v := range cmd[2:] {
+				l.PushBack(v)
+			}
+			db.versions[cmd[1]]++
+		}
+	case "LPOP", "RPOP":
+		if len(cmd) >= 3 {
+			var count int
+			fmt.Sscanf(cmd[2], "%d", &count)
+			db.popLocked(cmd[1], count, cmd[0] == "LPOP")
+		}
+	case "EXPIREAT":
+		if len(cmd) >= 3 {
+			var unixSeconds int64
+			fmt.Sscanf(cmd[2], "%d", &unixSeconds)
+			db.expires[cmd[1]] = time.Unix(unixSeconds, 0)
+		}
+	case "PERSIST":
+		if len(cmd) >= 2 {
+			delete(db.expires, cmd[1])
+		}
+	}
+}
+
+// LogCommand appends a mutating command to the AOF under the configured
+// fsync policy. It is a no-op for read-only commands.
+func (p *Persistence) LogCommand(cmd ...string) error {
+	return p.aof.append(cmd)
+}
+
+// BGSave atomically snapshots db's current data set, lists, and TTLs to
+// dump.rdb, then truncates the AOF. Everything BGSave just captured is in
+// dump.rdb, so the AOF only needs to hold commands from this point
+// forward — otherwise LoadInto would replay the whole file on top of the
+// snapshot and double-apply every command that predates it.
+//
+// db.mu is held for writing across both the snapshot capture and the AOF
+// truncation so no write can land in between: logged to the
+// about-to-be-truncated AOF but missing from the snapshot, which would
+// make it unrecoverable on restart.
+func (p *Persistence) BGSave(db *KeyValueDB) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := saveSnapshot(p.snapshotPath(), db.snapshotLocked()); err != nil {
+		return err
+	}
+	if err := p.aof.replace(nil); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.lastSave = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// RewriteAOF replaces the AOF with the minimal set of commands needed to
+// reconstruct db's current state (values, lists, and TTLs), bounding log
+// growth over time.
+func (p *Persistence) RewriteAOF(db *KeyValueDB) error {
+	var buf []byte
+	writer := resp.NewWriter(&sliceWriter{buf: &buf})
+	snap := db.Snapshot()
+
+	for k, v := range snap.Data {
+		writer.WriteArrayHeader(3)
+		writer.WriteBulkString("SET")
+		writer.WriteBulkString(k)
+		writer.WriteBulkString(v)
+	}
+	for k, values := range snap.Lists {
+		writer.WriteArrayHeader(2 + len(values))
+		writer.WriteBulkString("RPUSH")
+		writer.WriteBulkString(k)
+		for _, v := range values {
+			writer.WriteBulkString(v)
+		}
+	}
+	for k, t := range snap.Expires {
+		writer.WriteArrayHeader(3)
+		writer.WriteBulkString("EXPIREAT")
+		writer.WriteBulkString(k)
+		writer.WriteBulkString(strconv.FormatInt(t.Unix(), 10))
+	}
+	return p.aof.replace(buf)
+}
+
+// LastSave returns the unix timestamp of the most recent successful
+// BGSave, or 0 if none has run yet this process.
+func (p *Persistence) LastSave() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lastSave.IsZero() {
+		return 0
+	}
+	return p.lastSave.Unix()
+}
+
+// Sizes reports the current AOF and snapshot file sizes in bytes, for
+// monitoring how close either is to needing a rewrite/BGSAVE.
+func (p *Persistence) Sizes() (aofSize, rdbSize int64, err error) {
+	aofSize, err = p.aof.size()
+	if err != nil {
+		return 0, 0, err
+	}
+	if info, statErr := os.Stat(p.snapshotPath()); statErr == nil {
+		rdbSize = info.Size()
+	}
+	return aofSize, rdbSize, nil
+}
+
+// Close releases the AOF file handle and background fsync goroutine.
+func (p *Persistence) Close() error {
+	return p.aof.close()
+}
+
+// sliceWriter is an io.Writer over an in-memory []byte, used to build the
+// rewritten AOF contents before handing them to aof.replace.
+type sliceWriter struct {
+	buf *[]byte
+}
+
+func (s *sliceWriter) Write(b []byte) (int, error) {
+	*s.buf = append(*s.buf, b...)
+	return len(b), nil
+}
+
+// saveSnapshot writes snap to path atomically: it is fully written to a
+// temp file, fsynced, then renamed into place so a crash mid-write never
+// leaves a corrupt snapshot behind. The format is three sections, in
+// order: data (key/value pairs), lists (key + element count + elements),
+// and expires (key + absolute expiry as unix seconds).
+func saveSnapshot(path string, snap DBSnapshot) error {
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("store: create snapshot temp file: %w", err)
+	}
+
+	bw := bufio.NewWriter(f)
+	lenBuf := make([]byte, 4)
+	if err := func() error {
+		if err := writeSnapshotCount(bw, lenBuf, len(snap.Data)); err != nil {
+			return err
+		}
+		for k, v := range snap.Data {
+			if err := writeSnapshotField(bw, lenBuf, k); err != nil {
+				return err
+			}
+			if err := writeSnapshotField(bw, lenBuf, v); err != nil {
+				return err
+			}
+		}
+
+		if err := writeSnapshotCount(bw, lenBuf, len(snap.Lists)); err != nil {
+			return err
+		}
+		for k, values := range snap.Lists {
+			if err := writeSnapshotField(bw, lenBuf, k); err != nil {
+				return err
+			}
+			if err := writeSnapshotCount(bw, lenBuf, len(values)); err != nil {
+				return err
+			}
+			for _, v := range values {
+				if err := writeSnapshotField(bw, lenBuf, v); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := writeSnapshotCount(bw, lenBuf, len(snap.Expires)); err != nil {
+			return err
+		}
+		for k, t := range snap.Expires {
+			if err := writeSnapshotField(bw, lenBuf, k); err != nil {
+				return err
+			}
+			if err := writeSnapshotField(bw, lenBuf, strconv.FormatInt(t.Unix(), 10)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func writeSnapshotField(w io.Writer, lenBuf []byte, s string) error {
+	binary.BigEndian.PutUint32(lenBuf, uint32(len(s)))
+	if _, err := w.Write(lenBuf); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte(s))
+	return err
+}
+
+func writeSnapshotCount(w io.Writer, lenBuf []byte, n int) error {
+	binary.BigEndian.PutUint32(lenBuf, uint32(n))
+	_, err := w.Write(lenBuf)
+	return err
+}
+
+// loadSnapshot reads back the format written by saveSnapshot.
+func loadSnapshot(path string) (DBSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return DBSnapshot{}, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	lenBuf := make([]byte, 4)
+
+	dataCount, err := readSnapshotCount(br, lenBuf)
+	if err != nil {
+		return DBSnapshot{}, err
+	}
+	data := make(map[string]string, dataCount)
+	for i := 0; i < dataCount; i++ {
+		key, err := readSnapshotField(br, lenBuf)
+		if err != nil {
+			return DBSnapshot{}, err
+		}
+		value, err := readSnapshotField(br, lenBuf)
+		if err != nil {
+			return DBSnapshot{}, err
+		}
+		data[key] = value
+	}
+
+	listCount, err := readSnapshotCount(br, lenBuf)
+	if err != nil {
+		return DBSnapshot{}, err
+	}
+	lists := make(map[string][]string, listCount)
+	for i := 0; i < listCount; i++ {
+		key, err := readSnapshotField(br, lenBuf)
+		if err != nil {
+			return DBSnapshot{}, err
+		}
+		elemCount, err := readSnapshotCount(br, lenBuf)
+		if err != nil {
+			return DBSnapshot{}, err
+		}
+		values := make([]string, 0, elemCount)
+		for j := 0; j < elemCount; j++ {
+			v, err := readSnapshotField(br, lenBuf)
+			if err != nil {
+				return DBSnapshot{}, err
+			}
+			values = append(values, v)
+		}
+		lists[key] = values
+	}
+
+	expireCount, err := readSnapshotCount(br, lenBuf)
+	if err != nil {
+		return DBSnapshot{}, err
+	}
+	expires := make(map[string]time.Time, expireCount)
+	for i := 0; i < expireCount; i++ {
+		key, err := readSnapshotField(br, lenBuf)
+		if err != nil {
+			return DBSnapshot{}, err
+		}
+		unixSeconds, err := readSnapshotField(br, lenBuf)
+		if err != nil {
+			return DBSnapshot{}, err
+		}
+		seconds, err := strconv.ParseInt(unixSeconds, 10, 64)
+		if err != nil {
+			return DBSnapshot{}, fmt.Errorf("store: parse snapshot expiry: %w", err)
+		}
+		expires[key] = time.Unix(seconds, 0)
+	}
+
+	return DBSnapshot{Data: data, Lists: lists, Expires: expires}, nil
+}
+
+func readSnapshotField(r io.Reader, lenBuf []byte) (string, error) {
+	if _, err := io.ReadFull(r, lenBuf); err != nil {
+		return "", err
+	}
+	n := binary.BigEndian.Uint32(lenBuf)
+	buf := make([]byte, n)
+	if n > 0 {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+	}
+	return string(buf), nil
+}
+
+func readSnapshotCount(r io.Reader, lenBuf []byte) (int, error) {
+	if _, err := io.ReadFull(r, lenBuf); err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint32(lenBuf)), nil
+}
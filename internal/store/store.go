@@ -0,0 +1,259 @@
+// Package store implements the in-memory key/value database that backs
+// redisstore. It is kept independent of the network layer so it can be
+// driven directly (single-node mode) or through a Raft FSM (cluster mode).
+package store
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeyValueDB is the in-memory data store. All mutating operations are
+// safe for concurrent use. versions tracks a per-key monotonic counter
+// bumped on every successful write, so WATCH/EXEC can detect whether a
+// watched key changed since it was snapshotted.
+type KeyValueDB struct {
+	data     map[string]string
+	versions map[string]int64
+	mu       sync.RWMutex
+
+	// lists holds the LPUSH/RPUSH/... list data type, keyed separately
+	// from data so a key can be typechecked as "string" vs "list".
+	lists map[string]*list.List
+
+	// expires holds the absolute expiry time for keys with a TTL set via
+	// EXPIRE/PEXPIRE/SET ... EX/PX. A key with no entry never expires.
+	expires map[string]time.Time
+
+	// waitMu and waiters back BLPOP: a blocked client parks a buffered
+	// channel here and is woken by a push once it has released mu.
+	waitMu  sync.Mutex
+	waiters map[string][]chan struct{}
+
+	// persist is optional; when set, every mutating command is appended
+	// to the AOF before the method that applies it returns.
+	persist *Persistence
+}
+
+// NewKeyValueDB returns an empty, ready to use KeyValueDB.
+func NewKeyValueDB() *KeyValueDB {
+	db := &KeyValueDB{
+		data:     make(map[string]string),
+		versions: make(map[string]int64),
+		lists:    make(map[string]*list.List),
+		expires:  make(map[string]time.Time),
+		waiters:  make(map[string][]chan struct{}),
+	}
+	db.startActiveExpiration()
+	return db
+}
+
+// EnablePersistence attaches p to db so future mutations are appended to
+// its AOF. It does not load existing data; call p.LoadInto(db) first.
+func (db *KeyValueDB) EnablePersistence(p *Persistence) {
+	db.persist = p
+}
+
+// SetOptions controls the optional EX/PX/NX/XX behavior of SetWithOptions.
+type SetOptions struct {
+	TTL    time.Duration // ignored unless HasTTL is set
+	HasTTL bool
+	NX     bool // only set if key doesn't already exist
+	XX     bool // only set if key already exists
+}
+
+func (db *KeyValueDB) Set(key, value string) error {
+	_, err := db.SetWithOptions(key, value, SetOptions{})
+	return err
+}
+
+// SetWithOptions sets key to value, honoring EX/PX (HasTTL) and NX/XX
+// conditions. ok is false when NX/XX prevented the write; a plain SET
+// (HasTTL false) always clears any TTL previously set on key.
+func (db *KeyValueDB) SetWithOptions(key, value string, opts SetOptions) (ok bool, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.expireIfNeededLocked(key)
+	if _, isList := db.lists[key]; isList {
+		return false, ErrWrongType
+	}
+	_, exists := db.data[key]
+	if opts.NX && exists {
+		return false, nil
+	}
+	if opts.XX && !exists {
+		return false, nil
+	}
+	db.data[key] = value
+	db.versions[key]++
+	if opts.HasTTL {
+		db.expires[key] = time.Now().Add(opts.TTL)
+	} else {
+		delete(db.expires, key)
+	}
+	if db.persist != nil {
+		db.persist.LogCommand("SET", key, value)
+	}
+	return true, nil
+}
+
+// Get returns key's string value. It returns ErrWrongType if key holds a
+// list instead.
+func (db *KeyValueDB) Get(key string) (string, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.expireIfNeededLocked(key)
+	if _, isList := db.lists[key]; isList {
+		return "", false, ErrWrongType
+	}
+	val, ok := db.data[key]
+	return val, ok, nil
+}
+
+// Delete removes key, whether it holds a string or a list, reporting
+// whether it existed.
+func (db *KeyValueDB) Delete(key string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.expireIfNeededLocked(key)
+	return db.deleteKeyLocked(key)
+}
+
+// deleteKeyLocked removes key from data and lists (whichever holds it)
+// and bumps its version. Callers must already hold db.mu for writing.
+func (db *KeyValueDB) deleteKeyLocked(key string) bool {
+	_, inData := db.data[key]
+	_, inList := db.lists[key]
+	if !inData && !inList {
+		return false
+	}
+	delete(db.data, key)
+	delete(db.lists, key)
+	delete(db.expires, key)
+	db.versions[key]++
+	if db.persist != nil {
+		db.persist.LogCommand("DELETE", key)
+	}
+	return true
+}
+
+func (db *KeyValueDB) Incr(key string, by int64) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.expireIfNeededLocked(key)
+	if _, isList := db.lists[key]; isList {
+		return 0, ErrWrongType
+	}
+	current, err := db.incrLocked(key, by)
+	if err != nil {
+		return 0, err
+	}
+	db.versions[key]++
+	if db.persist != nil {
+		db.persist.LogCommand("INCRBY", key, strconv.FormatInt(by, 10))
+	}
+	return current, nil
+}
+
+// incrLocked performs the increment without touching versions or taking
+// the lock; callers must already hold db.mu and bump the version.
+func (db *KeyValueDB) incrLocked(key string, by int64) (int64, error) {
+	val, ok := db.data[key]
+	if !ok {
+		val = "0"
+	}
+
+	current, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ERR value is not an integer")
+	}
+
+	current += by
+	db.data[key] = strconv.FormatInt(current, 10)
+	return current, nil
+}
+
+// Versions snapshots the version counters for a set of keys in one pass,
+// used by WATCH when it is given multiple keys at once.
+func (db *KeyValueDB) Versions(keys []string) map[string]int64 {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	out := make(map[string]int64, len(keys))
+	for _, k := range keys {
+		out[k] = db.versions[k]
+	}
+	return out
+}
+
+// DBSnapshot is a point-in-time copy of everything a KeyValueDB needs to
+// fully reconstruct its state: string values, lists, and TTLs. Raft FSM
+// snapshots and durability checkpoints both round-trip through this.
+type DBSnapshot struct {
+	Data    map[string]string
+	Lists   map[string][]string
+	Expires map[string]time.Time
+}
+
+// Snapshot returns a copy of the current data set, lists, and TTLs,
+// suitable for a Raft FSM snapshot or a durability checkpoint.
+func (db *KeyValueDB) Snapshot() DBSnapshot {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.snapshotLocked()
+}
+
+// snapshotLocked builds the DBSnapshot returned by Snapshot. Callers must
+// already hold db.mu for reading (or writing, e.g. BGSave).
+func (db *KeyValueDB) snapshotLocked() DBSnapshot {
+	data := make(map[string]string, len(db.data))
+	for k, v := range db.data {
+		data[k] = v
+	}
+
+	lists := make(map[string][]string, len(db.lists))
+	for k, l := range db.lists {
+		values := make([]string, 0, l.Len())
+		for e := l.Front(); e != nil; e = e.Next() {
+			values = append(values, e.Value.(string))
+		}
+		lists[k] = values
+	}
+
+	expires := make(map[string]time.Time, len(db.expires))
+	for k, t := range db.expires {
+		expires[k] = t
+	}
+
+	return DBSnapshot{Data: data, Lists: lists, Expires: expires}
+}
+
+// Restore replaces the current data set, lists, and TTLs wholesale, e.g.
+// when a Raft follower installs a leader-sent snapshot.
+func (db *KeyValueDB) Restore(snap DBSnapshot) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.data = snap.Data
+	if db.data == nil {
+		db.data = make(map[string]string)
+	}
+	db.versions = make(map[string]int64, len(db.data))
+
+	db.lists = make(map[string]*list.List, len(snap.Lists))
+	for k, values := range snap.Lists {
+		l := list.New()
+		for _, v := range values {
+			l.PushBack(v)
+		}
+		db.lists[k] = l
+	}
+
+	db.expires = make(map[string]time.Time, len(snap.Expires))
+	for k, t := range snap.Expires {
+		db.expires[k] = t
+	}
+}
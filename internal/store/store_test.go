@@ -0,0 +1,34 @@
+package store
+
+import "testing"
+
+func TestGetIncrRejectListKey(t *testing.T) {
+	db := NewKeyValueDB()
+	db.RPush("k", "a", "b")
+
+	if _, _, err := db.Get("k"); err != ErrWrongType {
+		t.Errorf("Get(k) err = %v, want ErrWrongType", err)
+	}
+	if _, err := db.Incr("k", 1); err != ErrWrongType {
+		t.Errorf("Incr(k) err = %v, want ErrWrongType", err)
+	}
+
+	if n, err := db.LLen("k"); err != nil || n != 2 {
+		t.Errorf("LLen(k) = %d, %v, want 2, nil (list must be untouched)", n, err)
+	}
+}
+
+func TestDeleteRemovesListKey(t *testing.T) {
+	db := NewKeyValueDB()
+	db.RPush("k", "a", "b")
+
+	if !db.Delete("k") {
+		t.Fatal("Delete(k) = false, want true")
+	}
+	if n, err := db.LLen("k"); err != nil || n != 0 {
+		t.Errorf("LLen(k) = %d, %v after delete, want 0, nil", n, err)
+	}
+	if _, ok, _ := db.Get("k"); ok {
+		t.Errorf("Get(k) ok = true after delete, want false")
+	}
+}
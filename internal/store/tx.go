@@ -0,0 +1,102 @@
+package store
+
+import "strconv"
+
+// ReplyKind identifies the shape of a Reply so callers can translate it
+// into the matching RESP type without re-inspecting the command that
+// produced it.
+type ReplyKind int
+
+const (
+	ReplySimpleString ReplyKind = iota
+	ReplyBulkString
+	ReplyInteger
+	ReplyNil
+	ReplyError
+)
+
+// Reply is one queued command's result from a transaction.
+type Reply struct {
+	Kind ReplyKind
+	Str  string
+	Int  int64
+}
+
+// TxCommand is a single command queued inside a MULTI/EXEC block.
+type TxCommand struct {
+	Name  string // SET, GET, DELETE, INCR, INCRBY
+	Key   string
+	Value string
+	Delta int64
+}
+
+// ExecTx atomically validates watch against the current version counters
+// and, if nothing watched has changed, executes cmds in order under a
+// single write lock. It reports committed=false (and a nil reply slice)
+// if any watched key's version no longer matches, mirroring Redis's
+// optimistic-concurrency EXEC semantics.
+func (db *KeyValueDB) ExecTx(watch map[string]int64, cmds []TxCommand) (replies []Reply, committed bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for key, version := range watch {
+		if db.versions[key] != version {
+			return nil, false
+		}
+	}
+
+	replies = make([]Reply, 0, len(cmds))
+	for _, c := range cmds {
+		replies = append(replies, db.applyTxCommandLocked(c))
+	}
+	return replies, true
+}
+
+// applyTxCommandLocked executes a single queued command; callers must
+// already hold db.mu for writing. Every case lazily expires c.Key first,
+// the same way the non-transactional Set/Get/Delete/Incr methods do.
+func (db *KeyValueDB) applyTxCommandLocked(c TxCommand) Reply {
+	db.expireIfNeededLocked(c.Key)
+	switch c.Name {
+	case "SET":
+		if _, isList := db.lists[c.Key]; isList {
+			return Reply{Kind: ReplyError, Str: ErrWrongType.Error()}
+		}
+		db.data[c.Key] = c.Value
+		db.versions[c.Key]++
+		delete(db.expires, c.Key)
+		if db.persist != nil {
+			db.persist.LogCommand("SET", c.Key, c.Value)
+		}
+		return Reply{Kind: ReplySimpleString, Str: "OK"}
+	case "GET":
+		if _, isList := db.lists[c.Key]; isList {
+			return Reply{Kind: ReplyError, Str: ErrWrongType.Error()}
+		}
+		val, ok := db.data[c.Key]
+		if !ok {
+			return Reply{Kind: ReplyNil}
+		}
+		return Reply{Kind: ReplyBulkString, Str: val}
+	case "DELETE":
+		if db.deleteKeyLocked(c.Key) {
+			return Reply{Kind: ReplyInteger, Int: 1}
+		}
+		return Reply{Kind: ReplyInteger, Int: 0}
+	case "INCR", "INCRBY":
+		if _, isList := db.lists[c.Key]; isList {
+			return Reply{Kind: ReplyError, Str: ErrWrongType.Error()}
+		}
+		current, err := db.incrLocked(c.Key, c.Delta)
+		if err != nil {
+			return Reply{Kind: ReplyError, Str: err.Error()}
+		}
+		db.versions[c.Key]++
+		if db.persist != nil {
+			db.persist.LogCommand("INCRBY", c.Key, strconv.FormatInt(c.Delta, 10))
+		}
+		return Reply{Kind: ReplyInteger, Int: current}
+	default:
+		return Reply{Kind: ReplyError, Str: "ERR unknown command '" + c.Name + "'"}
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExecTxCommitsWhenWatchUnchanged(t *testing.T) {
+	db := NewKeyValueDB()
+	db.Set("foo", "1")
+	watch := db.Versions([]string{"foo"})
+
+	replies, committed := db.ExecTx(watch, []TxCommand{
+		{Name: "SET", Key: "foo", Value: "2"},
+		{Name: "GET", Key: "foo"},
+	})
+	if !committed {
+		t.Fatal("expected transaction to commit")
+	}
+	if replies[0].Kind != ReplySimpleString || replies[0].Str != "OK" {
+		t.Errorf("SET reply = %+v, want OK", replies[0])
+	}
+	if replies[1].Kind != ReplyBulkString || replies[1].Str != "2" {
+		t.Errorf("GET reply = %+v, want bulk \"2\"", replies[1])
+	}
+}
+
+func TestExecTxAbortsWhenWatchedKeyChanged(t *testing.T) {
+	db := NewKeyValueDB()
+	db.Set("foo", "1")
+	watch := db.Versions([]string{"foo"})
+
+	db.Set("foo", "2") // changes foo's version after the watch snapshot
+
+	replies, committed := db.ExecTx(watch, []TxCommand{
+		{Name: "SET", Key: "foo", Value: "3"},
+	})
+	if committed {
+		t.Fatal("expected transaction to abort")
+	}
+	if replies != nil {
+		t.Errorf("replies = %v, want nil", replies)
+	}
+	if val, _, _ := db.Get("foo"); val != "2" {
+		t.Errorf("foo = %q, want unchanged \"2\"", val)
+	}
+}
+
+func TestExecTxGetSeesLazyExpiry(t *testing.T) {
+	db := NewKeyValueDB()
+	db.SetWithOptions("foo", "bar", SetOptions{HasTTL: true, TTL: -time.Second})
+
+	replies, committed := db.ExecTx(nil, []TxCommand{
+		{Name: "GET", Key: "foo"},
+	})
+	if !committed {
+		t.Fatal("expected transaction to commit")
+	}
+	if replies[0].Kind != ReplyNil {
+		t.Errorf("GET reply = %+v, want nil (already expired)", replies[0])
+	}
+}
+
+func TestExecTxSetClearsExistingTTL(t *testing.T) {
+	db := NewKeyValueDB()
+	db.SetWithOptions("foo", "bar", SetOptions{HasTTL: true, TTL: time.Hour})
+
+	_, committed := db.ExecTx(nil, []TxCommand{
+		{Name: "SET", Key: "foo", Value: "baz"},
+	})
+	if !committed {
+		t.Fatal("expected transaction to commit")
+	}
+	if ttl := db.TTL("foo"); ttl != -1 {
+		t.Errorf("TTL(foo) = %d, want -1 (no expiry)", ttl)
+	}
+}
@@ -1,136 +1,40 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
-	"sync"
-)
-
-type KeyValueDB struct {
-	data   map[string]string
-	mu     sync.RWMutex
-	queues map[string][]string
-}
-
-func NewKeyValueDB() *KeyValueDB {
-	return &KeyValueDB{
-		data:   make(map[string]string),
-		queues: make(map[string][]string),
-	}
-}
-
-func (db *KeyValueDB) Set(key, value string) error {
-	if !isValidValue(value) {
-		return fmt.Errorf("ERR syntax error: Value should be enclosed in quotes")
-	}
-	db.mu.Lock()
-	defer db.mu.Unlock()
-	db.data[key] = value
-	return nil
-}
-
-func (db *KeyValueDB) Get(key string) (string, bool) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	val, ok := db.data[key]
-	return val, ok
-}
-
-func (db *KeyValueDB) Delete(key string) bool {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-	_, ok := db.data[key]
-	if ok {
-		delete(db.data, key)
-		return true
-	}
-	return false
-}
-
-func (db *KeyValueDB) Incr(key string, by int64) (int64, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	val, ok := db.data[key]
-	if !ok {
-		db.data[key] = "0"
-		val = "0"
-	}
-
-	current, err := strconv.ParseInt(val, 10, 64)
-	if err != nil {
-		return 0, fmt.Errorf("ERR value is not an integer")
-	}
-
-	current += by
-	db.data[key] = strconv.FormatInt(current, 10)
-	return current, nil
-}
-
-func (db *KeyValueDB) QueueCommand(txID, cmd string) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-	db.queues[txID] = append(db.queues[txID], cmd)
-}
+	"time"
 
-func isValidValue(value string) bool {
-	if strings.Contains(value, " ") {
-		return strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"")
-	}
-	return true
-}
-
-func (db *KeyValueDB) Exec(txID string) []string {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-	queue, ok := db.queues[txID]
-
-	if !ok {
-		return []string{"ERR Transaction does not exist"}
-	}
-	delete(db.queues, txID)
-
-	responses := []string{}
-	for _, cmd := range queue {
-		parts := strings.Fields(cmd)
-
-		if len(parts) == 0 {
-			continue
-		}
-
-		command := strings.ToUpper(parts[0])
-
-		switch command {
-		case "SET":
-			if len(parts) < 3 {
-				responses = append(responses, "Usage: SET <key> <value>")
-				continue
-			}
-			responses = append(responses, cmd)
+	"github.com/tiwari91/redisstore/internal/cluster"
+	"github.com/tiwari91/redisstore/internal/resp"
+	"github.com/tiwari91/redisstore/internal/store"
+)
 
-		default:
-			responses = append(responses, fmt.Sprintf("Unknown command: %s", command))
-		}
-	}
-	return responses
+// txState tracks the MULTI/EXEC and WATCH state for a single connection.
+// Redis transactions are per-connection, not global, so this lives on the
+// stack of handleClient rather than on the shared KeyValueDB.
+type txState struct {
+	active bool
+	dirty  bool
+	queued []store.TxCommand
+	watch  map[string]int64
 }
 
-func handleClient(conn net.Conn, db *KeyValueDB) {
+func handleClient(conn net.Conn, db *store.KeyValueDB, cl *cluster.Cluster, persist *store.Persistence) {
 	defer conn.Close()
-	reader := bufio.NewReader(conn)
-	var currentTxID string
+	reader := resp.NewReader(conn)
+	writer := resp.NewWriter(conn)
+	var tx txState
 
 	for {
-		cmd, err := reader.ReadString('\n')
+		parts, err := reader.ReadCommand()
 		if err != nil {
 			fmt.Println("Error reading command:", err)
 			return
 		}
-		cmd = strings.TrimSpace(cmd)
-		parts := strings.Fields(cmd)
 
 		if len(parts) == 0 {
 			continue
@@ -140,132 +44,729 @@ func handleClient(conn net.Conn, db *KeyValueDB) {
 
 		switch command {
 		case "SET", "GET", "DELETE", "INCR", "INCRBY":
-			if currentTxID == "" {
-				fmt.Println("command: ", command)
-				fmt.Println("parts: ", parts)
-				executeSingleCommand(command, parts, db, conn)
+			if tx.active {
+				queueTxCommand(&tx, command, parts, writer)
 			} else {
-				db.QueueCommand(currentTxID, cmd)
-				conn.Write([]byte("QUEUED\n"))
+				executeSingleCommand(command, parts, db, cl, writer)
 			}
+		case "LPUSH", "RPUSH", "LPOP", "RPOP", "LLEN", "LRANGE", "BLPOP":
+			executeListCommand(command, parts, db, cl, writer)
+		case "EXPIRE", "PEXPIRE", "EXPIREAT", "TTL", "PTTL", "PERSIST":
+			executeExpireCommand(command, parts, db, cl, writer)
 		case "MULTI":
-			currentTxID = "1"
-			conn.Write([]byte("OK\n"))
-		case "EXEC":
-			if currentTxID == "" {
-				conn.Write([]byte("ERR No transaction in progress\n"))
-			} else {
-				fmt.Println("currentTxID: ", currentTxID)
-				responses := db.Exec(currentTxID)
-				for _, resp := range responses {
-					//fmt.Println("key: ", resp[0])
-					//fmt.Println("cmd: ", resp[0:])
-					cmd := strings.Fields(resp[0:])
-
-					fmt.Println("command: ", strings.ToUpper(cmd[0]))
-					fmt.Println(" cmdParts: ", cmd)
-
-					executeSingleCommand(command, parts, db, conn)
-					conn.Write([]byte(fmt.Sprintf("%s\n", resp)))
-				}
-				currentTxID = ""
+			if tx.active {
+				writer.WriteError("ERR MULTI calls can not be nested")
+				continue
 			}
+			tx.active = true
+			tx.dirty = false
+			tx.queued = nil
+			writer.WriteSimpleString("OK")
+		case "WATCH":
+			if tx.active {
+				writer.WriteError("ERR WATCH inside MULTI is not allowed")
+				continue
+			}
+			if len(parts) < 2 {
+				writer.WriteError("ERR wrong number of arguments for 'watch' command")
+				continue
+			}
+			if tx.watch == nil {
+				tx.watch = make(map[string]int64)
+			}
+			for key, version := range db.Versions(parts[1:]) {
+				tx.watch[key] = version
+			}
+			writer.WriteSimpleString("OK")
+		case "UNWATCH":
+			tx.watch = nil
+			writer.WriteSimpleString("OK")
+		case "DISCARD":
+			if !tx.active {
+				writer.WriteError("ERR DISCARD without MULTI")
+				continue
+			}
+			tx = txState{}
+			writer.WriteSimpleString("OK")
+		case "EXEC":
+			handleExec(&tx, db, cl, writer)
+		case "RAFT.JOIN":
+			handleRaftJoin(parts, cl, writer)
+		case "RAFT.LEAVE":
+			handleRaftLeave(parts, cl, writer)
+		case "RAFT.STATS":
+			handleRaftStats(cl, writer)
+		case "BGSAVE":
+			handleBGSave(db, persist, writer)
+		case "AOF.REWRITE", "BGREWRITEAOF":
+			handleAOFRewrite(db, persist, writer)
+		case "LASTSAVE":
+			handleLastSave(persist, writer)
+		case "PERSISTENCE.STATS":
+			handlePersistenceStats(persist, writer)
 		case "DISCONNECT":
 			return
 		default:
-			conn.Write([]byte(fmt.Sprintf("Unknown command: %s\n", command)))
+			writer.WriteError(fmt.Sprintf("ERR unknown command '%s'", command))
+		}
+	}
+}
+
+// queueTxCommand validates and appends a command to the in-progress
+// transaction. A malformed command replies with an error immediately and
+// marks the transaction dirty, matching Redis: EXEC on a dirty
+// transaction is aborted without running anything.
+func queueTxCommand(tx *txState, command string, parts []string, w *resp.Writer) {
+	cmd, err := newTxCommand(command, parts)
+	if err != nil {
+		tx.dirty = true
+		w.WriteError(err.Error())
+		return
+	}
+	tx.queued = append(tx.queued, cmd)
+	w.WriteSimpleString("QUEUED")
+}
+
+// newTxCommand validates parts against command's arity and builds the
+// store.TxCommand that will be replayed by EXEC.
+func newTxCommand(command string, parts []string) (store.TxCommand, error) {
+	switch command {
+	case "SET":
+		if len(parts) < 3 {
+			return store.TxCommand{}, fmt.Errorf("ERR wrong number of arguments for 'set' command")
+		}
+		return store.TxCommand{Name: "SET", Key: parts[1], Value: strings.Join(parts[2:], " ")}, nil
+	case "GET":
+		if len(parts) < 2 {
+			return store.TxCommand{}, fmt.Errorf("ERR wrong number of arguments for 'get' command")
+		}
+		return store.TxCommand{Name: "GET", Key: parts[1]}, nil
+	case "DELETE":
+		if len(parts) < 2 {
+			return store.TxCommand{}, fmt.Errorf("ERR wrong number of arguments for 'delete' command")
 		}
+		return store.TxCommand{Name: "DELETE", Key: parts[1]}, nil
+	case "INCR":
+		if len(parts) < 2 {
+			return store.TxCommand{}, fmt.Errorf("ERR wrong number of arguments for 'incr' command")
+		}
+		return store.TxCommand{Name: "INCR", Key: parts[1], Delta: 1}, nil
+	case "INCRBY":
+		if len(parts) < 3 {
+			return store.TxCommand{}, fmt.Errorf("ERR wrong number of arguments for 'incrby' command")
+		}
+		delta, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return store.TxCommand{}, fmt.Errorf("ERR value is not an integer or out of range")
+		}
+		return store.TxCommand{Name: "INCRBY", Key: parts[1], Delta: delta}, nil
+	default:
+		return store.TxCommand{}, fmt.Errorf("ERR unknown command '%s'", command)
+	}
+}
+
+// handleExec runs (or aborts) the transaction queued on tx and always
+// resets tx afterward, mirroring Redis: EXEC clears watches and the queue
+// whether it commits, aborts on a dirty queue, or aborts on a changed
+// watched key.
+func handleExec(tx *txState, db *store.KeyValueDB, cl *cluster.Cluster, w *resp.Writer) {
+	if !tx.active {
+		w.WriteError("ERR EXEC without MULTI")
+		return
+	}
+	if tx.dirty {
+		*tx = txState{}
+		w.WriteError("EXECABORT Transaction discarded because of previous errors.")
+		return
+	}
+
+	watch, cmds := tx.watch, tx.queued
+	*tx = txState{}
+
+	if cl != nil {
+		result, err := cl.Exec(watch, cmds)
+		if err != nil {
+			writeClusterError(err, cl, w)
+			return
+		}
+		if !result.Committed {
+			w.WriteNullArray()
+			return
+		}
+		writeReplies(w, result.Replies)
+		return
 	}
+
+	replies, committed := db.ExecTx(watch, cmds)
+	if !committed {
+		w.WriteNullArray()
+		return
+	}
+	writeReplies(w, replies)
 }
 
-func executeSingleCommand(command string, parts []string, db *KeyValueDB, conn net.Conn) {
+// writeReplies writes a transaction's per-command results as a RESP array
+// of typed replies.
+func writeReplies(w *resp.Writer, replies []store.Reply) {
+	w.WriteArrayHeader(len(replies))
+	for _, r := range replies {
+		switch r.Kind {
+		case store.ReplySimpleString:
+			w.WriteSimpleString(r.Str)
+		case store.ReplyBulkString:
+			w.WriteBulkString(r.Str)
+		case store.ReplyInteger:
+			w.WriteInteger(r.Int)
+		case store.ReplyNil:
+			w.WriteNullBulkString()
+		case store.ReplyError:
+			w.WriteError(r.Str)
+		}
+	}
+}
+
+func executeSingleCommand(command string, parts []string, db *store.KeyValueDB, cl *cluster.Cluster, w *resp.Writer) {
 	switch command {
 	case "SET":
 		if len(parts) < 3 {
-			conn.Write([]byte("Usage: SET <key> <value>\n"))
+			w.WriteError("ERR wrong number of arguments for 'set' command")
 			return
 		}
 		key := parts[1]
-		value := strings.Join(parts[2:], " ")
-		if err := db.Set(key, value); err != nil {
-			conn.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+		value, opts, err := parseSetOptions(parts)
+		if err != nil {
+			w.WriteError(err.Error())
 			return
 		}
-		conn.Write([]byte("OK\n"))
+		if cl != nil {
+			if opts.HasTTL || opts.NX || opts.XX {
+				w.WriteError("ERR SET with EX/PX/NX/XX is not supported in cluster mode")
+				return
+			}
+			if _, err := cl.Set(key, value); err != nil {
+				writeClusterError(err, cl, w)
+				return
+			}
+			w.WriteSimpleString("OK")
+			return
+		}
+		ok, err := db.SetWithOptions(key, value, opts)
+		if err != nil {
+			w.WriteError(err.Error())
+			return
+		}
+		if !ok {
+			w.WriteNullBulkString()
+			return
+		}
+		w.WriteSimpleString("OK")
 	case "GET":
 		if len(parts) < 2 {
-			conn.Write([]byte("Usage: GET <key>\n"))
+			w.WriteError("ERR wrong number of arguments for 'get' command")
 			return
 		}
 		key := parts[1]
-		val, ok := db.Get(key)
+		val, ok, err := db.Get(key)
+		if err != nil {
+			w.WriteError(err.Error())
+			return
+		}
 		if ok {
-			conn.Write([]byte(fmt.Sprintf("%q\n", val)))
+			w.WriteBulkString(val)
 		} else {
-			conn.Write([]byte("(nil)\n"))
+			w.WriteNullBulkString()
 		}
 	case "DELETE":
 		if len(parts) < 2 {
-			conn.Write([]byte("Usage: DELETE <key>\n"))
+			w.WriteError("ERR wrong number of arguments for 'delete' command")
 			return
 		}
 		key := parts[1]
+		if cl != nil {
+			result, err := cl.Delete(key)
+			if err != nil {
+				writeClusterError(err, cl, w)
+				return
+			}
+			writeClusterIntegerResult(result, w)
+			return
+		}
 		if db.Delete(key) {
-			conn.Write([]byte("(integer) 1\n"))
+			w.WriteInteger(1)
 		} else {
-			conn.Write([]byte("(integer) 0\n"))
+			w.WriteInteger(0)
 		}
 	case "INCR":
 		if len(parts) < 2 {
-			conn.Write([]byte("Usage: INCR <key>\n"))
+			w.WriteError("ERR wrong number of arguments for 'incr' command")
 			return
 		}
 		key := parts[1]
-		_, err := db.Incr(key, 1)
+		if cl != nil {
+			result, err := cl.Incr(key, 1)
+			if err != nil {
+				writeClusterError(err, cl, w)
+				return
+			}
+			writeClusterIntegerResult(result, w)
+			return
+		}
+		val, err := db.Incr(key, 1)
 		if err != nil {
-			conn.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+			w.WriteError(err.Error())
 			return
 		}
-		conn.Write([]byte("OK\n"))
+		w.WriteInteger(val)
 	case "INCRBY":
 		if len(parts) < 3 {
-			conn.Write([]byte("Usage: INCRBY <key> <increment>\n"))
+			w.WriteError("ERR wrong number of arguments for 'incrby' command")
 			return
 		}
 		key := parts[1]
 		incrBy, err := strconv.ParseInt(parts[2], 10, 64)
 		if err != nil {
-			conn.Write([]byte("ERR invalid increment\n"))
+			w.WriteError("ERR value is not an integer or out of range")
+			return
+		}
+		if cl != nil {
+			result, err := cl.Incr(key, incrBy)
+			if err != nil {
+				writeClusterError(err, cl, w)
+				return
+			}
+			writeClusterIntegerResult(result, w)
 			return
 		}
-		_, err = db.Incr(key, incrBy)
+		val, err := db.Incr(key, incrBy)
 		if err != nil {
-			conn.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+			w.WriteError(err.Error())
 			return
 		}
-		conn.Write([]byte(fmt.Sprintf("(integer) %d\n", incrBy)))
+		w.WriteInteger(val)
 	default:
-		conn.Write([]byte(fmt.Sprintf("Unknown command: %s\n", command)))
+		w.WriteError(fmt.Sprintf("ERR unknown command '%s'", command))
 	}
 }
 
+// parseSetOptions strips the optional EX seconds / PX milliseconds / NX /
+// XX tokens off the tail of a SET command's parts, returning the plain
+// value (everything between the key and the first recognized option) and
+// the store.SetOptions they describe.
+func parseSetOptions(parts []string) (string, store.SetOptions, error) {
+	var opts store.SetOptions
+	end := len(parts)
+	for end > 3 {
+		switch strings.ToUpper(parts[end-1]) {
+		case "NX":
+			opts.NX = true
+			end--
+			continue
+		case "XX":
+			opts.XX = true
+			end--
+			continue
+		}
+		if end > 3 {
+			switch strings.ToUpper(parts[end-2]) {
+			case "EX":
+				seconds, err := strconv.ParseInt(parts[end-1], 10, 64)
+				if err != nil {
+					return "", store.SetOptions{}, fmt.Errorf("ERR value is not an integer or out of range")
+				}
+				opts.HasTTL = true
+				opts.TTL = time.Duration(seconds) * time.Second
+				end -= 2
+				continue
+			case "PX":
+				millis, err := strconv.ParseInt(parts[end-1], 10, 64)
+				if err != nil {
+					return "", store.SetOptions{}, fmt.Errorf("ERR value is not an integer or out of range")
+				}
+				opts.HasTTL = true
+				opts.TTL = time.Duration(millis) * time.Millisecond
+				end -= 2
+				continue
+			}
+		}
+		break
+	}
+	return strings.Join(parts[2:end], " "), opts, nil
+}
+
+// executeExpireCommand handles the key-expiry commands. TTL mutations are
+// not currently replicated through the Raft cluster, so they are rejected
+// outright in cluster mode rather than silently applying only to the node
+// the client happens to be connected to; TTL/PTTL, being reads, are not
+// restricted.
+func executeExpireCommand(command string, parts []string, db *store.KeyValueDB, cl *cluster.Cluster, w *resp.Writer) {
+	if cl != nil {
+		switch command {
+		case "EXPIRE", "PEXPIRE", "EXPIREAT", "PERSIST":
+			w.WriteError(fmt.Sprintf("ERR %s is not supported in cluster mode", command))
+			return
+		}
+	}
+	switch command {
+	case "EXPIRE", "PEXPIRE":
+		if len(parts) < 3 {
+			w.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command)))
+			return
+		}
+		n, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			w.WriteError("ERR value is not an integer or out of range")
+			return
+		}
+		ttl := time.Duration(n) * time.Second
+		if command == "PEXPIRE" {
+			ttl = time.Duration(n) * time.Millisecond
+		}
+		w.WriteInteger(boolToInt(db.Expire(parts[1], ttl)))
+	case "EXPIREAT":
+		if len(parts) < 3 {
+			w.WriteError("ERR wrong number of arguments for 'expireat' command")
+			return
+		}
+		unixSeconds, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			w.WriteError("ERR value is not an integer or out of range")
+			return
+		}
+		w.WriteInteger(boolToInt(db.ExpireAt(parts[1], time.Unix(unixSeconds, 0))))
+	case "TTL":
+		if len(parts) < 2 {
+			w.WriteError("ERR wrong number of arguments for 'ttl' command")
+			return
+		}
+		w.WriteInteger(db.TTL(parts[1]))
+	case "PTTL":
+		if len(parts) < 2 {
+			w.WriteError("ERR wrong number of arguments for 'pttl' command")
+			return
+		}
+		w.WriteInteger(db.PTTL(parts[1]))
+	case "PERSIST":
+		if len(parts) < 2 {
+			w.WriteError("ERR wrong number of arguments for 'persist' command")
+			return
+		}
+		w.WriteInteger(boolToInt(db.Persist(parts[1])))
+	}
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// executeListCommand handles the list data type commands. List mutations
+// are not currently replicated through the Raft cluster (unlike
+// SET/DELETE/INCR), so they are rejected outright in cluster mode rather
+// than silently applying only to the node the client happens to be
+// connected to; LLEN/LRANGE, being reads, are not restricted.
+func executeListCommand(command string, parts []string, db *store.KeyValueDB, cl *cluster.Cluster, w *resp.Writer) {
+	if cl != nil {
+		switch command {
+		case "LPUSH", "RPUSH", "LPOP", "RPOP", "BLPOP":
+			w.WriteError(fmt.Sprintf("ERR %s is not supported in cluster mode", command))
+			return
+		}
+	}
+	switch command {
+	case "LPUSH", "RPUSH":
+		if len(parts) < 3 {
+			w.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command)))
+			return
+		}
+		key, values := parts[1], parts[2:]
+		var n int64
+		var err error
+		if command == "LPUSH" {
+			n, err = db.LPush(key, values...)
+		} else {
+			n, err = db.RPush(key, values...)
+		}
+		if err != nil {
+			w.WriteError(err.Error())
+			return
+		}
+		w.WriteInteger(n)
+	case "LPOP", "RPOP":
+		if len(parts) < 2 {
+			w.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command)))
+			return
+		}
+		count := 1
+		if len(parts) >= 3 {
+			n, err := strconv.Atoi(parts[2])
+			if err != nil || n < 0 {
+				w.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+			count = n
+		}
+		var popped []string
+		var err error
+		if command == "LPOP" {
+			popped, err = db.LPop(parts[1], count)
+		} else {
+			popped, err = db.RPop(parts[1], count)
+		}
+		if err != nil {
+			w.WriteError(err.Error())
+			return
+		}
+		if popped == nil {
+			w.WriteNullBulkString()
+			return
+		}
+		w.WriteArrayHeader(len(popped))
+		for _, v := range popped {
+			w.WriteBulkString(v)
+		}
+	case "LLEN":
+		if len(parts) < 2 {
+			w.WriteError("ERR wrong number of arguments for 'llen' command")
+			return
+		}
+		n, err := db.LLen(parts[1])
+		if err != nil {
+			w.WriteError(err.Error())
+			return
+		}
+		w.WriteInteger(n)
+	case "LRANGE":
+		if len(parts) < 4 {
+			w.WriteError("ERR wrong number of arguments for 'lrange' command")
+			return
+		}
+		start, err := strconv.Atoi(parts[2])
+		if err != nil {
+			w.WriteError("ERR value is not an integer or out of range")
+			return
+		}
+		stop, err := strconv.Atoi(parts[3])
+		if err != nil {
+			w.WriteError("ERR value is not an integer or out of range")
+			return
+		}
+		values, err := db.LRange(parts[1], start, stop)
+		if err != nil {
+			w.WriteError(err.Error())
+			return
+		}
+		w.WriteArrayHeader(len(values))
+		for _, v := range values {
+			w.WriteBulkString(v)
+		}
+	case "BLPOP":
+		if len(parts) < 3 {
+			w.WriteError("ERR wrong number of arguments for 'blpop' command")
+			return
+		}
+		keys := parts[1 : len(parts)-1]
+		seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+		if err != nil || seconds < 0 {
+			w.WriteError("ERR timeout is not a float or out of range")
+			return
+		}
+		key, value, ok := db.BLPop(keys, time.Duration(seconds*float64(time.Second)))
+		if !ok {
+			w.WriteNullArray()
+			return
+		}
+		w.WriteArrayHeader(2)
+		w.WriteBulkString(key)
+		w.WriteBulkString(value)
+	}
+}
+
+// writeClusterError translates a cluster write failure into a RESP reply,
+// redirecting the client to the current leader when this node is a
+// follower instead of returning a bare error.
+func writeClusterError(err error, cl *cluster.Cluster, w *resp.Writer) {
+	if err == cluster.ErrNotLeader {
+		leader := cl.LeaderAddr()
+		if leader == "" {
+			w.WriteError("ERR no leader elected")
+			return
+		}
+		w.WriteError(fmt.Sprintf("MOVED %s", leader))
+		return
+	}
+	w.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+}
+
+// writeClusterIntegerResult writes a raft FSM result that is a plain
+// integer reply (DELETE/INCR/INCRBY's success value). Business-logic
+// failures are surfaced through OpResult.Err and handled by the caller
+// via writeClusterError before this is ever reached, so result is always
+// numeric here.
+func writeClusterIntegerResult(result string, w *resp.Writer) {
+	n, err := strconv.ParseInt(result, 10, 64)
+	if err != nil {
+		w.WriteError(fmt.Sprintf("ERR unexpected cluster result %q", result))
+		return
+	}
+	w.WriteInteger(n)
+}
+
+func handleRaftJoin(parts []string, cl *cluster.Cluster, w *resp.Writer) {
+	if cl == nil {
+		w.WriteError("ERR this node is not running in cluster mode")
+		return
+	}
+	if len(parts) < 3 {
+		w.WriteError("ERR wrong number of arguments for 'raft.join' command")
+		return
+	}
+	if err := cl.Join(parts[1], parts[2]); err != nil {
+		writeClusterError(err, cl, w)
+		return
+	}
+	w.WriteSimpleString("OK")
+}
+
+func handleRaftLeave(parts []string, cl *cluster.Cluster, w *resp.Writer) {
+	if cl == nil {
+		w.WriteError("ERR this node is not running in cluster mode")
+		return
+	}
+	if len(parts) < 2 {
+		w.WriteError("ERR wrong number of arguments for 'raft.leave' command")
+		return
+	}
+	if err := cl.Leave(parts[1]); err != nil {
+		writeClusterError(err, cl, w)
+		return
+	}
+	w.WriteSimpleString("OK")
+}
+
+func handleRaftStats(cl *cluster.Cluster, w *resp.Writer) {
+	if cl == nil {
+		w.WriteError("ERR this node is not running in cluster mode")
+		return
+	}
+	stats := cl.Stats()
+	w.WriteArrayHeader(len(stats) * 2)
+	for k, v := range stats {
+		w.WriteBulkString(k)
+		w.WriteBulkString(v)
+	}
+}
+
+func handleBGSave(db *store.KeyValueDB, persist *store.Persistence, w *resp.Writer) {
+	if persist == nil {
+		w.WriteError("ERR persistence is not enabled")
+		return
+	}
+	if err := persist.BGSave(db); err != nil {
+		w.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+		return
+	}
+	w.WriteSimpleString("Background saving started")
+}
+
+func handleAOFRewrite(db *store.KeyValueDB, persist *store.Persistence, w *resp.Writer) {
+	if persist == nil {
+		w.WriteError("ERR persistence is not enabled")
+		return
+	}
+	if err := persist.RewriteAOF(db); err != nil {
+		w.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+		return
+	}
+	w.WriteSimpleString("Background append only file rewriting started")
+}
+
+func handleLastSave(persist *store.Persistence, w *resp.Writer) {
+	if persist == nil {
+		w.WriteError("ERR persistence is not enabled")
+		return
+	}
+	w.WriteInteger(persist.LastSave())
+}
+
+func handlePersistenceStats(persist *store.Persistence, w *resp.Writer) {
+	if persist == nil {
+		w.WriteError("ERR persistence is not enabled")
+		return
+	}
+	aofSize, rdbSize, err := persist.Sizes()
+	if err != nil {
+		w.WriteError(fmt.Sprintf("ERR %s", err.Error()))
+		return
+	}
+	w.WriteArrayHeader(6)
+	w.WriteBulkString("aof_size_bytes")
+	w.WriteBulkString(strconv.FormatInt(aofSize, 10))
+	w.WriteBulkString("rdb_size_bytes")
+	w.WriteBulkString(strconv.FormatInt(rdbSize, 10))
+	w.WriteBulkString("last_save")
+	w.WriteBulkString(strconv.FormatInt(persist.LastSave(), 10))
+}
+
 func main() {
-	port := 4544
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	port := flag.Int("port", 4544, "TCP port to listen on")
+	raftAddr := flag.String("raft-addr", "", "Raft transport bind address, e.g. 127.0.0.1:7000 (enables cluster mode)")
+	raftDir := flag.String("raft-dir", "raft-data", "directory to store this node's Raft log and snapshots")
+	nodeID := flag.String("node-id", "", "unique Raft node ID (required in cluster mode)")
+	join := flag.String("join", "", "RAFT.JOIN this node against an existing leader at host:port instead of bootstrapping a new cluster")
+	dataDir := flag.String("data-dir", "data", "directory for the append-only file and RDB-style snapshot")
+	fsyncPolicy := flag.String("fsync", "everysec", "AOF fsync policy: always, everysec, or no")
+	flag.Parse()
+
+	db := store.NewKeyValueDB()
+
+	persist, err := store.Open(*dataDir, store.FsyncPolicy(*fsyncPolicy))
+	if err != nil {
+		fmt.Println("Error opening persistence:", err)
+		return
+	}
+	defer persist.Close()
+	if err := persist.LoadInto(db); err != nil {
+		fmt.Println("Error loading persisted data:", err)
+		return
+	}
+	db.EnablePersistence(persist)
+
+	var cl *cluster.Cluster
+	if *raftAddr != "" {
+		if *nodeID == "" {
+			fmt.Println("Error starting cluster mode: --node-id is required when --raft-addr is set")
+			return
+		}
+		cl, err = cluster.New(cluster.Config{
+			NodeID:    *nodeID,
+			RaftAddr:  *raftAddr,
+			RaftDir:   *raftDir,
+			Bootstrap: *join == "",
+		}, db)
+		if err != nil {
+			fmt.Println("Error starting cluster:", err)
+			return
+		}
+		if *join != "" {
+			fmt.Printf("Node %s started; send RAFT.JOIN %s %s to the leader at %s to join the cluster\n", *nodeID, *nodeID, *raftAddr, *join)
+		}
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
 	if err != nil {
 		fmt.Println("Error starting server:", err)
 		return
 	}
 	defer listener.Close()
 
-	db := NewKeyValueDB()
-
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
 			fmt.Println("Error accepting connection:", err)
 			continue
 		}
-		go handleClient(conn, db)
+		go handleClient(conn, db, cl, persist)
 	}
 }